@@ -0,0 +1,61 @@
+// Package ratio holds the per-1K-token price ratios (relative to
+// gpt-3.5-turbo, priced at ratio 1) the billing step multiplies a request's
+// token counts by to charge quota. Every model billed through this service
+// should have an entry here; GetModelRatio falls back to a conservative
+// default for anything that doesn't, logging so the gap gets noticed rather
+// than silently under- or over-billing. GetCompletionRatio falls back to 1
+// without logging.
+package ratio
+
+import (
+	"fmt"
+
+	"github.com/songquanpeng/one-api/common/logger"
+)
+
+// defaultModelRatio is charged for a model with no entry in ModelRatio.
+// It's deliberately on the high side so an unpriced model errs toward
+// overcharging (and getting noticed) rather than undercharging silently.
+const defaultModelRatio = 2.5
+
+// defaultCompletionRatio is used for a model with no entry in
+// CompletionRatio, i.e. completion tokens bill the same as prompt tokens.
+const defaultCompletionRatio = 1.0
+
+// ModelRatio is the per-1K-token price ratio for every model this service
+// bills for that isn't priced by one of the provider-specific tables
+// elsewhere (OpenAI's own models are priced directly against their posted
+// per-1K rate; this table exists for everything bedrock/other adaptors add).
+//
+// https://aws.amazon.com/bedrock/pricing/
+var ModelRatio = map[string]float64{
+	"llama3-8b-instruct":  0.15,
+	"llama3-70b-instruct": 1.325,
+}
+
+// CompletionRatio is the completion/prompt price ratio for models that bill
+// input and output tokens at different rates. Models absent here use
+// defaultCompletionRatio.
+var CompletionRatio = map[string]float64{
+	"llama3-8b-instruct":  1,
+	"llama3-70b-instruct": 1,
+}
+
+// GetModelRatio returns the billing ratio for name, logging and falling back
+// to defaultModelRatio if name has no registered entry.
+func GetModelRatio(name string) float64 {
+	if ratio, ok := ModelRatio[name]; ok {
+		return ratio
+	}
+	logger.SysLog(fmt.Sprintf("no model ratio found for %s, using default ratio %.2f", name, defaultModelRatio))
+	return defaultModelRatio
+}
+
+// GetCompletionRatio returns the completion/prompt ratio for name, falling
+// back to defaultCompletionRatio if name has no registered entry.
+func GetCompletionRatio(name string) float64 {
+	if ratio, ok := CompletionRatio[name]; ok {
+		return ratio
+	}
+	return defaultCompletionRatio
+}
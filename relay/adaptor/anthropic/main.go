@@ -0,0 +1,251 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// stopReasonToOpenAI maps Anthropic's stop_reason to an OpenAI finish_reason.
+func stopReasonToOpenAI(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
+// ConvertRequest translates an OpenAI-style chat completion request into an
+// Anthropic Messages API request: system messages are joined into the
+// top-level system field, plain string content is kept as a string, and a
+// content-part list keeps only its text parts (as text blocks; image and
+// other parts are dropped). Tools/functions and any assistant tool_calls /
+// tool-result messages are translated into Anthropic's
+// tools/tool_use/tool_result shapes so a multi-turn tool-calling
+// conversation round-trips correctly.
+func ConvertRequest(textRequest *relaymodel.GeneralOpenAIRequest) (*Request, error) {
+	req := &Request{
+		Model:       textRequest.Model,
+		MaxTokens:   textRequest.MaxTokens,
+		Temperature: textRequest.Temperature,
+		TopP:        textRequest.TopP,
+		Stream:      textRequest.Stream,
+	}
+	if req.MaxTokens == 0 {
+		req.MaxTokens = 4096
+	}
+	if stop, ok := textRequest.Stop.(string); ok && stop != "" {
+		req.StopSequences = []string{stop}
+	} else if stops, ok := textRequest.Stop.([]string); ok {
+		req.StopSequences = stops
+	}
+
+	for _, m := range textRequest.Messages {
+		if m.Role == "system" {
+			req.System += m.StringContent()
+			continue
+		}
+		content, err := convertMessageContent(m)
+		if err != nil {
+			return nil, errors.Wrap(err, "convert message content")
+		}
+		role := m.Role
+		if role == "tool" {
+			// Anthropic carries tool_result blocks on a user turn.
+			role = "user"
+		}
+		req.Messages = append(req.Messages, Message{Role: role, Content: content})
+	}
+
+	req.Tools = convertTools(textRequest.Tools, textRequest.Functions)
+	req.ToolChoice = convertToolChoice(textRequest.ToolChoice)
+
+	return req, nil
+}
+
+// convertMessageContent turns one OpenAI message into an Anthropic content
+// value: a plain string for string content, a list of text blocks for a
+// content-part list, or a list of content blocks once tool_calls or a tool
+// result needs representing (Claude has no equivalent
+// of OpenAI's separate tool_calls/tool_call_id fields; both live as content
+// blocks instead).
+func convertMessageContent(m relaymodel.Message) (any, error) {
+	if m.Role == "tool" {
+		return []Content{{
+			Type:      "tool_result",
+			ToolUseId: m.ToolCallId,
+			Content:   m.StringContent(),
+		}}, nil
+	}
+
+	if len(m.ToolCalls) == 0 {
+		if parts, ok := m.Content.([]any); ok {
+			textBlocks := make([]Content, 0, len(parts))
+			for _, partAny := range parts {
+				part, ok := partAny.(map[string]any)
+				if !ok || part["type"] != "text" {
+					continue
+				}
+				text, _ := part["text"].(string)
+				textBlocks = append(textBlocks, Content{Type: "text", Text: text})
+			}
+			return textBlocks, nil
+		}
+		return m.StringContent(), nil
+	}
+
+	blocks := make([]Content, 0, len(m.ToolCalls)+1)
+	if text := m.StringContent(); text != "" {
+		blocks = append(blocks, Content{Type: "text", Text: text})
+	}
+	for _, call := range m.ToolCalls {
+		input := json.RawMessage(call.Function.Arguments)
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		blocks = append(blocks, Content{
+			Type:  "tool_use",
+			Id:    call.Id,
+			Name:  call.Function.Name,
+			Input: input,
+		})
+	}
+	return blocks, nil
+}
+
+// convertTools merges OpenAI's current tools field and its deprecated
+// functions field into Anthropic's flat tool list.
+func convertTools(tools []relaymodel.Tool, functions []relaymodel.Function) []Tool {
+	if len(tools) == 0 && len(functions) == 0 {
+		return nil
+	}
+	converted := make([]Tool, 0, len(tools)+len(functions))
+	for _, t := range tools {
+		converted = append(converted, Tool{
+			Name:        t.Function.Name,
+			Description: t.Function.Description,
+			InputSchema: t.Function.Parameters,
+		})
+	}
+	for _, f := range functions {
+		converted = append(converted, Tool{
+			Name:        f.Name,
+			Description: f.Description,
+			InputSchema: f.Parameters,
+		})
+	}
+	return converted
+}
+
+// convertToolChoice maps OpenAI's tool_choice ("auto"/"none"/"required" or
+// {"type":"function","function":{"name":...}}) onto Anthropic's
+// {"type":"auto"|"any"|"tool","name":...} shape.
+func convertToolChoice(choice any) any {
+	switch v := choice.(type) {
+	case nil:
+		return nil
+	case string:
+		switch v {
+		case "none":
+			return nil
+		case "required":
+			return map[string]string{"type": "any"}
+		default:
+			return map[string]string{"type": "auto"}
+		}
+	case map[string]any:
+		fn, ok := v["function"].(map[string]any)
+		if !ok {
+			return map[string]string{"type": "auto"}
+		}
+		name, _ := fn["name"].(string)
+		return map[string]string{"type": "tool", "name": name}
+	default:
+		return map[string]string{"type": "auto"}
+	}
+}
+
+// ResponseClaude2OpenAI converts a non-streaming Anthropic response into an
+// OpenAI chat-completion response. Text blocks are concatenated into the
+// message content and tool_use content blocks become tool_calls on the
+// single returned choice.
+func ResponseClaude2OpenAI(response *Response) *relaymodel.TextResponse {
+	message := relaymodel.Message{Role: "assistant"}
+	var text string
+	var toolCalls []relaymodel.Tool
+	for _, block := range response.Content {
+		switch block.Type {
+		case "text":
+			text += block.Text
+		case "tool_use":
+			toolCalls = append(toolCalls, relaymodel.Tool{
+				Id:   block.Id,
+				Type: "function",
+				Function: relaymodel.Function{
+					Name:      block.Name,
+					Arguments: string(block.Input),
+				},
+			})
+		}
+	}
+	message.Content = text
+	message.ToolCalls = toolCalls
+
+	finishReason := stopReasonToOpenAI(response.StopReason)
+	return &relaymodel.TextResponse{
+		Id:      fmt.Sprintf("chatcmpl-%s", response.Id),
+		Object:  "chat.completion",
+		Choices: []relaymodel.TextResponseChoice{{Index: 0, Message: message, FinishReason: finishReason}},
+		Usage: relaymodel.Usage{
+			PromptTokens:             response.Usage.InputTokens,
+			CompletionTokens:         response.Usage.OutputTokens,
+			TotalTokens:              response.Usage.InputTokens + response.Usage.OutputTokens,
+			CacheCreationInputTokens: response.Usage.CacheCreationInputTokens,
+			CacheReadInputTokens:     response.Usage.CacheReadInputTokens,
+		},
+	}
+}
+
+// ResponseMeta carries the bookkeeping a message_start/message_delta
+// streaming event reports (message id, incremental usage) back to the
+// caller, separately from the OpenAI-shaped delta chunk itself.
+type ResponseMeta struct {
+	Id    string
+	Usage Usage
+}
+
+// StreamResponseClaude2OpenAI converts one Anthropic streaming event into an
+// OpenAI chat-completion chunk. A content_block_delta carrying non-empty
+// text_delta returns (chunk, nil); message_start, and message_delta when it
+// has usage, return (nil, meta) since they carry usage/id bookkeeping rather
+// than visible text. Every other event returns (nil, nil).
+func StreamResponseClaude2OpenAI(response *StreamResponse) (*relaymodel.ChatCompletionsStreamResponse, *ResponseMeta) {
+	switch response.Type {
+	case "message_start":
+		return nil, &ResponseMeta{Id: response.Message.Id, Usage: response.Message.Usage}
+	case "message_delta":
+		if response.Usage == nil {
+			return nil, nil
+		}
+		return nil, &ResponseMeta{Usage: *response.Usage}
+	case "content_block_delta":
+		if response.Delta.Type != "text_delta" || response.Delta.Text == "" {
+			return nil, nil
+		}
+		return &relaymodel.ChatCompletionsStreamResponse{
+			Object: "chat.completion.chunk",
+			Choices: []relaymodel.ChatCompletionsStreamResponseChoice{
+				{Delta: relaymodel.Message{Content: response.Delta.Text}},
+			},
+		}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,110 @@
+// Package anthropic converts between the OpenAI-compatible request/response
+// shapes in relay/model and Anthropic's own Messages API wire format, so
+// every adaptor that talks to a Claude model (directly, or via Bedrock in
+// relay/adaptor/aws) can share one conversion.
+//
+// https://docs.anthropic.com/en/api/messages
+package anthropic
+
+import "encoding/json"
+
+// Tool is an Anthropic tool definition, given to the model as part of a
+// request's tools list.
+type Tool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema,omitempty"`
+}
+
+// Content is one block of a Claude message: plain text, an in-progress or
+// completed tool_use call, or a tool_result answering one. CacheControl is
+// the prompt-caching breakpoint extension; set it to mark "cache from here
+// back" on a block.
+//
+// https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
+type Content struct {
+	Type         string          `json:"type"`
+	Text         string          `json:"text,omitempty"`
+	Id           string          `json:"id,omitempty"`
+	Name         string          `json:"name,omitempty"`
+	Input        json.RawMessage `json:"input,omitempty"`
+	ToolUseId    string          `json:"tool_use_id,omitempty"`
+	Content      any             `json:"content,omitempty"`
+	CacheControl *CacheControl   `json:"cache_control,omitempty"`
+}
+
+// CacheControl marks a content block as a prompt-caching breakpoint. "type"
+// is currently always "ephemeral" on Anthropic's API.
+type CacheControl struct {
+	Type string `json:"type"`
+}
+
+// Message is one turn of an Anthropic Messages API conversation. Content is
+// `any` (rather than []Content) because a plain system/user turn with no
+// tool use or caching is just a string, and callers that only round-trip
+// unmodified JSON (e.g. relay/adaptor/aws's copier.Copy) shouldn't have to
+// go through the typed Content shape to do it.
+type Message struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
+
+// Usage is Anthropic's token-accounting block. CacheCreationInputTokens and
+// CacheReadInputTokens are only present once a request used cache_control
+// breakpoints; both bill at a different rate than a normal input token.
+//
+// https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
+type Usage struct {
+	InputTokens              int `json:"input_tokens"`
+	OutputTokens             int `json:"output_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// Request is an Anthropic Messages API request body.
+type Request struct {
+	Model         string    `json:"model,omitempty"`
+	Messages      []Message `json:"messages"`
+	System        string    `json:"system,omitempty"`
+	MaxTokens     int       `json:"max_tokens,omitempty"`
+	Temperature   float64   `json:"temperature,omitempty"`
+	TopP          float64   `json:"top_p,omitempty"`
+	TopK          int       `json:"top_k,omitempty"`
+	StopSequences []string  `json:"stop_sequences,omitempty"`
+	Stream        bool      `json:"stream,omitempty"`
+	Tools         []Tool    `json:"tools,omitempty"`
+	ToolChoice    any       `json:"tool_choice,omitempty"`
+}
+
+// Response is a non-streaming Anthropic Messages API response.
+type Response struct {
+	Id           string    `json:"id"`
+	Type         string    `json:"type"`
+	Role         string    `json:"role"`
+	Content      []Content `json:"content"`
+	Model        string    `json:"model"`
+	StopReason   string    `json:"stop_reason"`
+	StopSequence string    `json:"stop_sequence"`
+	Usage        Usage     `json:"usage"`
+}
+
+// StreamResponse is the superset of Anthropic's streaming event shapes
+// (message_start, content_block_delta, message_delta, message_stop, ...);
+// only the fields a given event type actually sets are populated.
+type StreamResponse struct {
+	Type  string `json:"type"`
+	Index int    `json:"index"`
+	Message struct {
+		Id    string `json:"id"`
+		Usage Usage  `json:"usage"`
+	} `json:"message"`
+	ContentBlock *Content `json:"content_block,omitempty"`
+	Delta        struct {
+		Type         string `json:"type"`
+		Text         string `json:"text"`
+		PartialJSON  string `json:"partial_json"`
+		StopReason   string `json:"stop_reason"`
+		StopSequence string `json:"stop_sequence"`
+	} `json:"delta"`
+	Usage *Usage `json:"usage,omitempty"`
+}
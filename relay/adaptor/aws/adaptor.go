@@ -0,0 +1,380 @@
+// Package aws provides the AWS adaptor for the relay service.
+package aws
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime"
+	"github.com/aws/aws-sdk-go-v2/service/bedrockruntime/types"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/model"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// AwsAdapter converts between the OpenAI-facing request/response shapes and
+// the Bedrock-native wire format for a single model family (Claude, Llama 3,
+// ...). Handler and StreamHandler dispatch to the adapter registered for the
+// requested model in awsModelFamilies.
+type AwsAdapter interface {
+	// ConvertRequest builds the Bedrock InvokeModel request body out of the
+	// OpenAI-shaped request that Relay already converted and stashed on the
+	// gin context.
+	ConvertRequest(c *gin.Context) ([]byte, error)
+	// DoResponse turns a non-streaming Bedrock response body into an OpenAI
+	// chat-completion response, writes it to c and reports usage.
+	DoResponse(c *gin.Context, awsBody []byte, modelName string) (*relaymodel.Usage, error)
+	// DoStreamResponse turns a single Bedrock response-stream chunk into zero
+	// or more OpenAI SSE chunks written to c. usage is non-nil for each chunk
+	// that carries token counts; a family may report them across several
+	// chunks (Claude: message_start and message_delta), and the caller sums
+	// them.
+	DoStreamResponse(c *gin.Context, createdTime int64, chunk []byte) (usage *relaymodel.Usage, err error)
+}
+
+func newAwsClient(creds *awsCredentials, region string) *bedrockruntime.Client {
+	return bedrockruntime.New(bedrockruntime.Options{
+		Region:      region,
+		Credentials: aws.NewCredentialsCache(credentials.NewStaticCredentialsProvider(creds.AccessKeyID, creds.SecretAccessKey, "")),
+	})
+}
+
+func wrapErr(err error) *relaymodel.ErrorWithStatusCode {
+	return &relaymodel.ErrorWithStatusCode{
+		StatusCode: http.StatusInternalServerError,
+		Error: relaymodel.Error{
+			Message: fmt.Sprintf("%+v", err),
+		},
+	}
+}
+
+// awsModelFamily pairs the Bedrock model id for a requested model with the
+// AwsAdapter that knows how to speak that family's wire format.
+type awsModelFamily struct {
+	awsModelId string
+	adapter    func() AwsAdapter
+}
+
+// awsModelFamilies is the per-model-family registry. Adding support for a new
+// Bedrock model family means adding entries here plus an AwsAdapter
+// implementation, not branching inside Handler/StreamHandler.
+//
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-ids.html
+var awsModelFamilies = map[string]awsModelFamily{
+	"claude-instant-1.2":       {"anthropic.claude-instant-v1", newClaudeAdapter},
+	"claude-2.0":               {"anthropic.claude-v2", newClaudeAdapter},
+	"claude-2.1":               {"anthropic.claude-v2:1", newClaudeAdapter},
+	"claude-3-sonnet-20240229": {"anthropic.claude-3-sonnet-20240229-v1:0", newClaudeAdapter},
+	"claude-3-opus-20240229":   {"anthropic.claude-3-opus-20240229-v1:0", newClaudeAdapter},
+	"claude-3-haiku-20240307":  {"anthropic.claude-3-haiku-20240307-v1:0", newClaudeAdapter},
+	"llama3-8b-instruct":       {"meta.llama3-8b-instruct-v1:0", newLlama3Adapter},
+	"llama3-70b-instruct":      {"meta.llama3-70b-instruct-v1:0", newLlama3Adapter},
+}
+
+// awsModelID resolves the requested model name to the Bedrock model id and
+// the adapter responsible for translating that family's request/response
+// shapes.
+func awsModelID(requestModel string) (awsModelId string, adapter AwsAdapter, err error) {
+	family, ok := awsModelFamilies[requestModel]
+	if !ok {
+		return "", nil, errors.Errorf("unknown model: %s", requestModel)
+	}
+	return family.awsModelId, family.adapter(), nil
+}
+
+func Handler(c *gin.Context, resp *http.Response, promptTokens int, modelName string) (*relaymodel.ErrorWithStatusCode, *relaymodel.Usage) {
+	var channel *model.Channel
+	if channeli, ok := c.Get(common.CtxKeyChannel); !ok {
+		return wrapErr(errors.New("channel not found")), nil
+	} else {
+		channel = channeli.(*model.Channel)
+	}
+
+	familyModelId, adapter, err := awsModelID(c.GetString(common.CtxKeyRequestModel))
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "awsModelID")), nil
+	}
+
+	body, err := adapter.ConvertRequest(c)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "convert request")), nil
+	}
+
+	creds, err := parseAwsCredentials(channel)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "parseAwsCredentials")), nil
+	}
+
+	targets := orderedRegionTargets(channel.Id, parseRegionTargets(*channel.BaseURL))
+	var respBody []byte
+	var invokeErr error
+	for i, target := range targets {
+		modelId := familyModelId
+		if target.ProfileID != "" {
+			modelId = target.ProfileID
+		}
+
+		if useSigV4HTTP(channel) {
+			respBody, invokeErr = invokeModelSigV4(c, creds, target.Region, modelId, body)
+		} else {
+			awsResp, err := newAwsClient(creds, target.Region).InvokeModel(c.Request.Context(), &bedrockruntime.InvokeModelInput{
+				ModelId:     aws.String(modelId),
+				Accept:      aws.String("application/json"),
+				ContentType: aws.String("application/json"),
+				Body:        body,
+			})
+			invokeErr = err
+			if err == nil {
+				respBody = awsResp.Body
+			}
+		}
+
+		if invokeErr == nil {
+			c.Set(CtxKeyAwsRegion, target.Region)
+			break
+		}
+		if i == len(targets)-1 || !isRetryableBedrockError(invokeErr) {
+			break
+		}
+		logger.SysLog(fmt.Sprintf("aws region %s failed with retryable error, failing over: %s", target.Region, invokeErr.Error()))
+		coolDownRegion(channel.Id, target.Region)
+	}
+	if invokeErr != nil {
+		return wrapErr(errors.Wrap(invokeErr, "InvokeModel")), nil
+	}
+
+	usage, err := adapter.DoResponse(c, respBody, modelName)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "do response")), nil
+	}
+
+	logRegionUsage(channel.Id, modelName, c.GetString(CtxKeyAwsRegion), usage)
+	return nil, usage
+}
+
+// logRegionUsage records which of a channel's failover regions actually
+// served the request alongside the usage it produced, so multi-region
+// channels can be debugged from the log without guessing which region a
+// given request landed on.
+func logRegionUsage(channelId int, modelName, region string, usage *relaymodel.Usage) {
+	if region == "" {
+		return
+	}
+	logger.SysLog(fmt.Sprintf("aws channel %d model %s served from region %s (prompt_tokens=%d completion_tokens=%d)",
+		channelId, modelName, region, usage.PromptTokens, usage.CompletionTokens))
+}
+
+// invokeModelSigV4 is the raw-HTTP counterpart of bedrockruntime.Client's
+// InvokeModel, used when the channel has opted into useSigV4HTTP.
+func invokeModelSigV4(c *gin.Context, creds *awsCredentials, region, modelId string, body []byte) ([]byte, error) {
+	httpResp, err := signedInvokeModelRequest(c, creds, region, modelId, body, false)
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+	return io.ReadAll(httpResp.Body)
+}
+
+func StreamHandler(c *gin.Context, resp *http.Response) (*relaymodel.ErrorWithStatusCode, *relaymodel.Usage) {
+	createdTime := helper.GetTimestamp()
+
+	var channel *model.Channel
+	if channeli, ok := c.Get(common.CtxKeyChannel); !ok {
+		return wrapErr(errors.New("channel not found")), nil
+	} else {
+		channel = channeli.(*model.Channel)
+	}
+
+	familyModelId, adapter, err := awsModelID(c.GetString(common.CtxKeyRequestModel))
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "awsModelID")), nil
+	}
+
+	body, err := adapter.ConvertRequest(c)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "convert request")), nil
+	}
+
+	creds, err := parseAwsCredentials(channel)
+	if err != nil {
+		return wrapErr(errors.Wrap(err, "parseAwsCredentials")), nil
+	}
+
+	// Establishing the stream can fail over across regions just like
+	// Handler does; once events start flowing we've already committed the
+	// response headers, so a mid-stream error is surfaced as-is instead.
+	targets := orderedRegionTargets(channel.Id, parseRegionTargets(*channel.BaseURL))
+	var sigv4Body io.ReadCloser
+	var sdkStream *bedrockruntime.InvokeModelWithResponseStreamEventStream
+	var invokeErr error
+	for i, target := range targets {
+		modelId := familyModelId
+		if target.ProfileID != "" {
+			modelId = target.ProfileID
+		}
+
+		if useSigV4HTTP(channel) {
+			var httpResp *http.Response
+			httpResp, invokeErr = signedInvokeModelRequest(c, creds, target.Region, modelId, body, true)
+			if invokeErr == nil {
+				sigv4Body = httpResp.Body
+			}
+		} else {
+			var awsResp *bedrockruntime.InvokeModelWithResponseStreamOutput
+			awsResp, invokeErr = newAwsClient(creds, target.Region).InvokeModelWithResponseStream(c.Request.Context(), &bedrockruntime.InvokeModelWithResponseStreamInput{
+				ModelId:     aws.String(modelId),
+				Accept:      aws.String("application/json"),
+				ContentType: aws.String("application/json"),
+				Body:        body,
+			})
+			if invokeErr == nil {
+				sdkStream = awsResp.GetStream()
+			}
+		}
+
+		if invokeErr == nil {
+			c.Set(CtxKeyAwsRegion, target.Region)
+			break
+		}
+		if i == len(targets)-1 || !isRetryableBedrockError(invokeErr) {
+			break
+		}
+		logger.SysLog(fmt.Sprintf("aws region %s failed with retryable error, failing over: %s", target.Region, invokeErr.Error()))
+		coolDownRegion(channel.Id, target.Region)
+	}
+	if invokeErr != nil {
+		return wrapErr(errors.Wrap(invokeErr, "InvokeModelWithResponseStream")), nil
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	var usage relaymodel.Usage
+
+	if sigv4Body != nil {
+		defer sigv4Body.Close()
+		streamSigV4Response(c, sigv4Body, adapter, createdTime, &usage)
+		logRegionUsage(channel.Id, c.GetString(common.CtxKeyRequestModel), c.GetString(CtxKeyAwsRegion), &usage)
+		return nil, &usage
+	}
+
+	defer sdkStream.Close()
+	c.Stream(func(w io.Writer) bool {
+		event, ok := <-sdkStream.Events()
+		if !ok {
+			if err := sdkStream.Err(); err != nil {
+				logger.SysError("bedrock stream error: " + err.Error())
+				c.Render(-1, common.CustomEvent{Data: "data: " + streamErrorEventJSON(err)})
+				return false
+			}
+			c.Render(-1, common.CustomEvent{Data: "data: [DONE]"})
+			return false
+		}
+
+		switch v := event.(type) {
+		case *types.ResponseStreamMemberChunk:
+			chunkUsage, err := adapter.DoStreamResponse(c, createdTime, v.Value.Bytes)
+			if err != nil {
+				return false
+			}
+			if chunkUsage != nil {
+				usage.PromptTokens += chunkUsage.PromptTokens
+				usage.CompletionTokens += chunkUsage.CompletionTokens
+				usage.TotalTokens += chunkUsage.TotalTokens
+			}
+			return true
+		case *types.UnknownUnionMember:
+			fmt.Println("unknown tag:", v.Tag)
+			return false
+		default:
+			fmt.Println("union is nil or unknown type")
+			return false
+		}
+	})
+
+	logRegionUsage(channel.Id, c.GetString(common.CtxKeyRequestModel), c.GetString(CtxKeyAwsRegion), &usage)
+	return nil, &usage
+}
+
+// streamSigV4Response reads the raw AWS event-stream framing off body (the
+// shape bedrockruntime.Client's SDK path would otherwise decode for us) and
+// feeds each chunk's payload through adapter.DoStreamResponse, accumulating
+// usage exactly like the SDK path's c.Stream loop does.
+func streamSigV4Response(c *gin.Context, body io.Reader, adapter AwsAdapter, createdTime int64, usage *relaymodel.Usage) {
+	r := bufio.NewReader(body)
+	for {
+		msg, err := readEventStreamMessage(r)
+		if err != nil {
+			if err != io.EOF {
+				logger.SysError("error reading event-stream message: " + err.Error())
+				c.Render(-1, common.CustomEvent{Data: "data: " + streamErrorEventJSON(err)})
+				return
+			}
+			break
+		}
+		if msg.MessageType == "exception" {
+			logger.SysError("bedrock stream exception: " + bedrockEventStreamExceptionError(msg).Error())
+			c.Render(-1, common.CustomEvent{Data: "data: " + streamErrorEventJSON(bedrockEventStreamExceptionError(msg))})
+			return
+		}
+		if msg.MessageType != "event" || msg.EventType != "chunk" {
+			continue
+		}
+
+		var framed struct {
+			Bytes string `json:"bytes"`
+		}
+		if err := json.Unmarshal(msg.Payload, &framed); err != nil {
+			logger.SysError("error unmarshalling event-stream payload: " + err.Error())
+			continue
+		}
+		chunk, err := base64.StdEncoding.DecodeString(framed.Bytes)
+		if err != nil {
+			logger.SysError("error decoding event-stream chunk: " + err.Error())
+			continue
+		}
+
+		chunkUsage, err := adapter.DoStreamResponse(c, createdTime, chunk)
+		if err != nil {
+			break
+		}
+		if chunkUsage != nil {
+			usage.PromptTokens += chunkUsage.PromptTokens
+			usage.CompletionTokens += chunkUsage.CompletionTokens
+			usage.TotalTokens += chunkUsage.TotalTokens
+		}
+	}
+	c.Render(-1, common.CustomEvent{Data: "data: [DONE]"})
+}
+
+// bedrockEventStreamExceptionError decodes an event-stream frame whose
+// :message-type is "exception" (e.g. a mid-stream ThrottlingException) into
+// the same bedrockErrorBody shape the raw-HTTP path uses for non-2xx
+// responses, falling back to the frame's :event-type when the payload isn't
+// the usual {"message":...,"__type":...} JSON.
+func bedrockEventStreamExceptionError(msg *eventStreamMessage) error {
+	var be bedrockErrorBody
+	if err := json.Unmarshal(msg.Payload, &be); err != nil || be.Type == "" {
+		return errors.Errorf("bedrock stream exception (%s): %s", msg.EventType, string(msg.Payload))
+	}
+	return errors.Errorf("bedrock stream exception: %s: %s", be.Type, be.Message)
+}
+
+// streamErrorEventJSON renders err as the SSE error payload clients expect
+// when a stream fails partway through, instead of silently emitting
+// "data: [DONE]" as if the response had completed normally.
+func streamErrorEventJSON(err error) string {
+	jsonStr, marshalErr := json.Marshal(wrapErr(err))
+	if marshalErr != nil {
+		return `{"error":{"message":"bedrock stream error"}}`
+	}
+	return string(jsonStr)
+}
@@ -0,0 +1,364 @@
+package aws
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/copier"
+	"github.com/pkg/errors"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/logger"
+	"github.com/songquanpeng/one-api/relay/adaptor/anthropic"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// claudeAdapter speaks the Anthropic Claude wire format used by Bedrock's
+// anthropic.* models. It keeps the streaming message id and any in-flight
+// tool_use blocks around so every SSE chunk of a response can be assembled
+// correctly.
+type claudeAdapter struct {
+	id    string
+	tools map[int]*toolCallState
+}
+
+// toolCallState accumulates one tool_use content block's streamed
+// input_json_delta fragments until content_block_stop closes it out.
+type toolCallState struct {
+	id   string
+	name string
+	args strings.Builder
+}
+
+func newClaudeAdapter() AwsAdapter {
+	return &claudeAdapter{}
+}
+
+// Anthropic's Bedrock cache pricing: writing to the cache costs ~1.25x a
+// normal input token, reading from it costs ~0.1x.
+//
+// https://docs.aws.amazon.com/bedrock/latest/userguide/prompt-caching.html
+const (
+	cacheWriteRatio = 1.25
+	cacheReadRatio  = 0.1
+)
+
+// billingPromptTokens folds Anthropic's cache_creation/cache_read token
+// counts into a single effective prompt-token count, so the generic
+// PromptTokens*ModelRatio billing step downstream prices discounted cache
+// tokens correctly without needing to know caching exists.
+func billingPromptTokens(inputTokens, cacheCreationTokens, cacheReadTokens int) int {
+	return inputTokens +
+		int(float64(cacheCreationTokens)*cacheWriteRatio) +
+		int(float64(cacheReadTokens)*cacheReadRatio)
+}
+
+func (a *claudeAdapter) ConvertRequest(c *gin.Context) ([]byte, error) {
+	claudeReqi, ok := c.Get(common.CtxKeyConvertedRequest)
+	if !ok {
+		return nil, errors.New("request not found")
+	}
+	claudeReq := claudeReqi.(*anthropic.Request)
+
+	awsClaudeReq := &Request{
+		AnthropicVersion: "bedrock-2023-05-31",
+	}
+	if err := copier.Copy(awsClaudeReq, claudeReq); err != nil {
+		return nil, errors.Wrap(err, "copy request")
+	}
+
+	body, err := json.Marshal(awsClaudeReq)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal request")
+	}
+
+	// anthropic.Request has no field for OpenAI's messages[].content[].cache_control
+	// extension, so copier.Copy above can't carry it across. Recover it by
+	// re-reading the original request body and splicing cache_control back
+	// onto the matching content blocks of the Bedrock payload. This is
+	// best-effort: if the original body can't be read a second time (e.g.
+	// something upstream already drained it) we still ship the request,
+	// just without cache breakpoints, rather than fail it outright.
+	body, applyErr := applyCacheControlExtension(c, body)
+	if applyErr != nil {
+		logger.SysError("error applying cache_control extension: " + applyErr.Error())
+		return body, nil
+	}
+	return body, nil
+}
+
+// applyCacheControlExtension re-attaches any messages[].content[].cache_control
+// breakpoints from the original OpenAI-style request body onto the
+// already-built Bedrock request body, matching messages and content blocks
+// positionally.
+//
+// https://docs.anthropic.com/en/docs/build-with-claude/prompt-caching
+func applyCacheControlExtension(c *gin.Context, body []byte) ([]byte, error) {
+	origBody, err := peekRequestBody(c)
+	if err != nil {
+		return body, err
+	}
+
+	var orig struct {
+		Messages []struct {
+			Role    string          `json:"role"`
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(origBody, &orig); err != nil {
+		return body, errors.Wrap(err, "unmarshal original request")
+	}
+	// System messages were lifted into the top-level system field, so they
+	// are skipped here to keep both message lists aligned.
+	origContents := make([]json.RawMessage, 0, len(orig.Messages))
+	for _, m := range orig.Messages {
+		if m.Role != "system" {
+			origContents = append(origContents, m.Content)
+		}
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return body, errors.Wrap(err, "unmarshal bedrock request")
+	}
+	var messages []json.RawMessage
+	if err := json.Unmarshal(doc["messages"], &messages); err != nil {
+		return body, errors.Wrap(err, "unmarshal bedrock messages")
+	}
+
+	changed := false
+	for i := range messages {
+		if i >= len(origContents) {
+			break
+		}
+		var msg map[string]json.RawMessage
+		if err := json.Unmarshal(messages[i], &msg); err != nil {
+			continue
+		}
+		blocks, breakpoints, ok := cacheControlBreakpoints(origContents[i], msg["content"])
+		if !ok {
+			continue
+		}
+		for j, cc := range breakpoints {
+			if cc == nil {
+				continue
+			}
+			blocks[j]["cache_control"] = cc
+			changed = true
+		}
+		mergedContent, err := json.Marshal(blocks)
+		if err != nil {
+			return body, errors.Wrap(err, "marshal content blocks")
+		}
+		msg["content"] = mergedContent
+		mergedMsg, err := json.Marshal(msg)
+		if err != nil {
+			return body, errors.Wrap(err, "marshal message")
+		}
+		messages[i] = mergedMsg
+	}
+	if !changed {
+		return body, nil
+	}
+
+	messagesJSON, err := json.Marshal(messages)
+	if err != nil {
+		return body, errors.Wrap(err, "marshal messages")
+	}
+	doc["messages"] = messagesJSON
+	return json.Marshal(doc)
+}
+
+// cacheControlBreakpoints pairs up an original OpenAI-style message's content
+// blocks with the corresponding Bedrock content blocks and reports which
+// indices carry a cache_control breakpoint to copy over. ok is false when
+// either side isn't a content-block array (e.g. plain string content, which
+// can't carry a breakpoint).
+func cacheControlBreakpoints(origContent, bedrockContent json.RawMessage) (blocks []map[string]json.RawMessage, breakpoints []json.RawMessage, ok bool) {
+	var origBlocks []struct {
+		CacheControl json.RawMessage `json:"cache_control"`
+	}
+	if err := json.Unmarshal(origContent, &origBlocks); err != nil {
+		return nil, nil, false
+	}
+	if err := json.Unmarshal(bedrockContent, &blocks); err != nil {
+		return nil, nil, false
+	}
+	if len(blocks) != len(origBlocks) {
+		return nil, nil, false
+	}
+
+	breakpoints = make([]json.RawMessage, len(origBlocks))
+	any := false
+	for i, b := range origBlocks {
+		if len(b.CacheControl) > 0 {
+			breakpoints[i] = b.CacheControl
+			any = true
+		}
+	}
+	if !any {
+		return nil, nil, false
+	}
+	return blocks, breakpoints, true
+}
+
+// peekRequestBody reads c.Request.Body and restores it so later code can
+// still read it the normal way.
+func peekRequestBody(c *gin.Context) ([]byte, error) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
+
+// DoResponse writes the real token counts to the client in openaiResp.Usage
+// (cache_creation_input_tokens/cache_read_input_tokens are informational
+// additions, not a reason to change what prompt_tokens/total_tokens mean) and
+// returns a separate, cache-ratio-weighted relaymodel.Usage for the billing
+// step to charge quota against. The two must not be the same value: a client
+// reading its own usage back should see actual tokens, not a cost-weighted
+// number dressed up as one.
+func (a *claudeAdapter) DoResponse(c *gin.Context, awsBody []byte, modelName string) (*relaymodel.Usage, error) {
+	claudeResponse := new(anthropic.Response)
+	if err := json.Unmarshal(awsBody, claudeResponse); err != nil {
+		return nil, errors.Wrap(err, "unmarshal response")
+	}
+
+	openaiResp := anthropic.ResponseClaude2OpenAI(claudeResponse)
+	openaiResp.Model = modelName
+	openaiResp.Usage = relaymodel.Usage{
+		PromptTokens:             claudeResponse.Usage.InputTokens,
+		CompletionTokens:         claudeResponse.Usage.OutputTokens,
+		TotalTokens:              claudeResponse.Usage.InputTokens + claudeResponse.Usage.OutputTokens,
+		CacheCreationInputTokens: claudeResponse.Usage.CacheCreationInputTokens,
+		CacheReadInputTokens:     claudeResponse.Usage.CacheReadInputTokens,
+	}
+
+	c.JSON(200, openaiResp)
+
+	billingUsage := openaiResp.Usage
+	billingUsage.PromptTokens = billingPromptTokens(
+		claudeResponse.Usage.InputTokens,
+		claudeResponse.Usage.CacheCreationInputTokens,
+		claudeResponse.Usage.CacheReadInputTokens,
+	)
+	billingUsage.TotalTokens = billingUsage.PromptTokens + billingUsage.CompletionTokens
+	return &billingUsage, nil
+}
+
+// claudeStreamEvent is a superset probe of the Anthropic streaming event
+// shapes we need to notice tool_use blocks in. anthropic.StreamResponseClaude2OpenAI
+// only translates text and usage/meta events, so content_block_start/delta
+// tool_use handling is done directly off the raw chunk here instead.
+type claudeStreamEvent struct {
+	Type         string `json:"type"`
+	Index        int    `json:"index"`
+	ContentBlock struct {
+		Type string `json:"type"`
+		Id   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"content_block"`
+	Delta struct {
+		Type        string `json:"type"`
+		PartialJSON string `json:"partial_json"`
+	} `json:"delta"`
+}
+
+func (a *claudeAdapter) DoStreamResponse(c *gin.Context, createdTime int64, chunk []byte) (*relaymodel.Usage, error) {
+	var event claudeStreamEvent
+	if err := json.Unmarshal(chunk, &event); err == nil {
+		switch event.Type {
+		case "content_block_start":
+			if event.ContentBlock.Type == "tool_use" {
+				if a.tools == nil {
+					a.tools = make(map[int]*toolCallState)
+				}
+				state := &toolCallState{id: event.ContentBlock.Id, name: event.ContentBlock.Name}
+				a.tools[event.Index] = state
+				a.emitToolCallDelta(c, createdTime, event.Index, state, true, "")
+				return nil, nil
+			}
+		case "content_block_delta":
+			if event.Delta.Type == "input_json_delta" {
+				if state, ok := a.tools[event.Index]; ok {
+					state.args.WriteString(event.Delta.PartialJSON)
+					a.emitToolCallDelta(c, createdTime, event.Index, state, false, event.Delta.PartialJSON)
+					return nil, nil
+				}
+			}
+		case "content_block_stop":
+			if _, ok := a.tools[event.Index]; ok {
+				delete(a.tools, event.Index)
+				return nil, nil
+			}
+		}
+	}
+
+	claudeResp := new(anthropic.StreamResponse)
+	if err := json.NewDecoder(bytes.NewReader(chunk)).Decode(claudeResp); err != nil {
+		logger.SysError("error unmarshalling stream response: " + err.Error())
+		return nil, err
+	}
+
+	response, meta := anthropic.StreamResponseClaude2OpenAI(claudeResp)
+	if meta != nil {
+		a.id = fmt.Sprintf("chatcmpl-%s", meta.Id)
+		promptTokens := billingPromptTokens(meta.Usage.InputTokens, meta.Usage.CacheCreationInputTokens, meta.Usage.CacheReadInputTokens)
+		return &relaymodel.Usage{
+			PromptTokens:             promptTokens,
+			CompletionTokens:         meta.Usage.OutputTokens,
+			TotalTokens:              promptTokens + meta.Usage.OutputTokens,
+			CacheCreationInputTokens: meta.Usage.CacheCreationInputTokens,
+			CacheReadInputTokens:     meta.Usage.CacheReadInputTokens,
+		}, nil
+	}
+	if response == nil {
+		return nil, nil
+	}
+	response.Id = a.id
+	response.Model = c.GetString(common.CtxKeyOriginModel)
+	response.Created = createdTime
+	jsonStr, err := json.Marshal(response)
+	if err != nil {
+		logger.SysError("error marshalling stream response: " + err.Error())
+		return nil, nil
+	}
+	c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonStr)})
+	return nil, nil
+}
+
+// emitToolCallDelta writes one OpenAI tool_calls streaming delta. The first
+// delta for a tool call (start=true) carries its id/type/function name with
+// an empty arguments string, matching what OpenAI's own API sends; every
+// delta after that carries only the next fragment of function.arguments.
+func (a *claudeAdapter) emitToolCallDelta(c *gin.Context, createdTime int64, index int, state *toolCallState, start bool, argsFragment string) {
+	toolCall := relaymodel.Tool{Index: &index, Type: "function"}
+	if start {
+		toolCall.Id = state.id
+		toolCall.Function.Name = state.name
+	}
+	toolCall.Function.Arguments = argsFragment
+
+	response := relaymodel.ChatCompletionsStreamResponse{
+		Id:      a.id,
+		Object:  "chat.completion.chunk",
+		Created: createdTime,
+		Model:   c.GetString(common.CtxKeyOriginModel),
+		Choices: []relaymodel.ChatCompletionsStreamResponseChoice{
+			{
+				Delta: relaymodel.Message{ToolCalls: []relaymodel.Tool{toolCall}},
+			},
+		},
+	}
+	jsonStr, err := json.Marshal(response)
+	if err != nil {
+		logger.SysError("error marshalling tool call delta: " + err.Error())
+		return
+	}
+	c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonStr)})
+}
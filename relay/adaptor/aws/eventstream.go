@@ -0,0 +1,105 @@
+package aws
+
+import (
+	"bufio"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// eventStreamMessage is one decoded frame of the AWS event-stream binary
+// encoding Bedrock uses for InvokeModelWithResponseStream. We only care about
+// the ":event-type"/":message-type" headers and the payload; the prelude and
+// message CRCs are verified and everything else in the prelude/header
+// section is discarded.
+//
+// https://docs.aws.amazon.com/transcribe/latest/dg/event-stream.html
+type eventStreamMessage struct {
+	MessageType string // ":message-type" header, e.g. "event" or "exception"
+	EventType   string // ":event-type" header, e.g. "chunk"
+	Payload     []byte
+}
+
+// readEventStreamMessage reads exactly one framed message from r. It returns
+// io.EOF once the stream is exhausted between messages.
+func readEventStreamMessage(r *bufio.Reader) (*eventStreamMessage, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return nil, err
+	}
+	totalLen := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	preludeCRC := binary.BigEndian.Uint32(prelude[8:12])
+
+	if crc32.ChecksumIEEE(prelude[:8]) != preludeCRC {
+		return nil, errors.New("event-stream: corrupt prelude (crc mismatch)")
+	}
+	if totalLen < 16 || int(totalLen) < 16+int(headersLen) {
+		return nil, errors.New("event-stream: invalid frame length")
+	}
+
+	rest := make([]byte, totalLen-12)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, errors.Wrap(err, "read frame")
+	}
+
+	// The trailing message CRC covers the whole frame up to itself,
+	// prelude included.
+	messageCRC := crc32.NewIEEE()
+	messageCRC.Write(prelude)
+	messageCRC.Write(rest[:len(rest)-4])
+	if messageCRC.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return nil, errors.New("event-stream: corrupt message (crc mismatch)")
+	}
+
+	headerBytes := rest[:headersLen]
+	payload := rest[headersLen : len(rest)-4]
+
+	headers, err := parseEventStreamHeaders(headerBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse headers")
+	}
+
+	msg := make([]byte, len(payload))
+	copy(msg, payload)
+	return &eventStreamMessage{
+		MessageType: headers[":message-type"],
+		EventType:   headers[":event-type"],
+		Payload:     msg,
+	}, nil
+}
+
+// parseEventStreamHeaders decodes the repeated (name, type, value) header
+// records. Bedrock only ever sends string-typed headers for InvokeModel
+// streams, so that's the only value type handled.
+func parseEventStreamHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		nameLen := int(b[0])
+		b = b[1:]
+		if len(b) < nameLen+1 {
+			return nil, errors.New("truncated header name")
+		}
+		name := string(b[:nameLen])
+		b = b[nameLen:]
+
+		valueType := b[0]
+		b = b[1:]
+		if valueType != 7 { // 7 == string
+			return nil, errors.Errorf("unsupported header value type %d", valueType)
+		}
+		if len(b) < 2 {
+			return nil, errors.New("truncated header value length")
+		}
+		valLen := int(binary.BigEndian.Uint16(b[:2]))
+		b = b[2:]
+		if len(b) < valLen {
+			return nil, errors.New("truncated header value")
+		}
+		headers[name] = string(b[:valLen])
+		b = b[valLen:]
+	}
+	return headers, nil
+}
@@ -0,0 +1,174 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/songquanpeng/one-api/common"
+	"github.com/songquanpeng/one-api/common/helper"
+	"github.com/songquanpeng/one-api/common/logger"
+	relaymodel "github.com/songquanpeng/one-api/relay/model"
+)
+
+// Llama3Request is the Bedrock wire format for meta.llama3-*-instruct-v1:0.
+//
+// https://docs.aws.amazon.com/bedrock/latest/userguide/model-parameters-meta.html
+type Llama3Request struct {
+	Prompt      string  `json:"prompt"`
+	MaxGenLen   int     `json:"max_gen_len,omitempty"`
+	Temperature float64 `json:"temperature,omitempty"`
+	TopP        float64 `json:"top_p,omitempty"`
+}
+
+// Llama3Response is the Bedrock response shape for a non-streaming
+// meta.llama3-*-instruct-v1:0 invocation. The same fields (minus
+// generation_token_count, which is only final) show up incrementally in each
+// streamed chunk.
+type Llama3Response struct {
+	Generation           string `json:"generation"`
+	PromptTokenCount     int    `json:"prompt_token_count"`
+	GenerationTokenCount int    `json:"generation_token_count"`
+	StopReason           string `json:"stop_reason"`
+}
+
+const llama3DefaultMaxGenLen = 512
+
+// llama3Adapter speaks Bedrock's Llama 3 completion format, which is a flat
+// prompt/generation string rather than Claude's structured messages.
+type llama3Adapter struct {
+	id string
+}
+
+func newLlama3Adapter() AwsAdapter {
+	return &llama3Adapter{}
+}
+
+func (a *llama3Adapter) ConvertRequest(c *gin.Context) ([]byte, error) {
+	textReqi, ok := c.Get(common.CtxKeyConvertedRequest)
+	if !ok {
+		return nil, errors.New("request not found")
+	}
+	textReq := textReqi.(*relaymodel.GeneralOpenAIRequest)
+
+	maxGenLen := llama3DefaultMaxGenLen
+	if textReq.MaxTokens > 0 {
+		maxGenLen = textReq.MaxTokens
+	}
+
+	llamaReq := &Llama3Request{
+		Prompt:      llama3Prompt(textReq.Messages),
+		MaxGenLen:   maxGenLen,
+		Temperature: textReq.Temperature,
+		TopP:        textReq.TopP,
+	}
+
+	return json.Marshal(llamaReq)
+}
+
+// llama3Prompt renders OpenAI chat messages using Llama 3's instruction
+// chat template so Bedrock sees a single well-formed prompt string.
+//
+// https://llama.meta.com/docs/model-cards-and-prompt-formats/meta-llama-3/
+func llama3Prompt(messages []relaymodel.Message) string {
+	var b strings.Builder
+	b.WriteString("<|begin_of_text|>")
+	for _, m := range messages {
+		fmt.Fprintf(&b, "<|start_header_id|>%s<|end_header_id|>\n\n%s<|eot_id|>", m.Role, m.StringContent())
+	}
+	b.WriteString("<|start_header_id|>assistant<|end_header_id|>\n\n")
+	return b.String()
+}
+
+func (a *llama3Adapter) DoResponse(c *gin.Context, awsBody []byte, modelName string) (*relaymodel.Usage, error) {
+	llamaResp := new(Llama3Response)
+	if err := json.Unmarshal(awsBody, llamaResp); err != nil {
+		return nil, errors.Wrap(err, "unmarshal response")
+	}
+
+	usage := relaymodel.Usage{
+		PromptTokens:     llamaResp.PromptTokenCount,
+		CompletionTokens: llamaResp.GenerationTokenCount,
+		TotalTokens:      llamaResp.PromptTokenCount + llamaResp.GenerationTokenCount,
+	}
+
+	openaiResp := relaymodel.TextResponse{
+		Id:      fmt.Sprintf("chatcmpl-%s", helper.GetUUID()),
+		Object:  "chat.completion",
+		Created: helper.GetTimestamp(),
+		Model:   modelName,
+		Choices: []relaymodel.TextResponseChoice{
+			{
+				Index: 0,
+				Message: relaymodel.Message{
+					Role:    "assistant",
+					Content: llamaResp.Generation,
+				},
+				FinishReason: llama3StopReason(llamaResp.StopReason),
+			},
+		},
+		Usage: usage,
+	}
+
+	c.JSON(200, openaiResp)
+	return &usage, nil
+}
+
+func (a *llama3Adapter) DoStreamResponse(c *gin.Context, createdTime int64, chunk []byte) (*relaymodel.Usage, error) {
+	llamaResp := new(Llama3Response)
+	if err := json.Unmarshal(chunk, llamaResp); err != nil {
+		logger.SysError("error unmarshalling stream response: " + err.Error())
+		return nil, err
+	}
+
+	if a.id == "" {
+		a.id = fmt.Sprintf("chatcmpl-%s", helper.GetUUID())
+	}
+
+	response := relaymodel.ChatCompletionsStreamResponse{
+		Id:      a.id,
+		Object:  "chat.completion.chunk",
+		Created: createdTime,
+		Model:   c.GetString(common.CtxKeyOriginModel),
+		Choices: []relaymodel.ChatCompletionsStreamResponseChoice{
+			{
+				Delta: relaymodel.Message{Content: llamaResp.Generation},
+			},
+		},
+	}
+	if llamaResp.StopReason != "" {
+		reason := llama3StopReason(llamaResp.StopReason)
+		response.Choices[0].FinishReason = &reason
+	}
+
+	jsonStr, err := json.Marshal(response)
+	if err != nil {
+		logger.SysError("error marshalling stream response: " + err.Error())
+		return nil, nil
+	}
+	c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonStr)})
+
+	if llamaResp.StopReason == "" {
+		return nil, nil
+	}
+	return &relaymodel.Usage{
+		PromptTokens:     llamaResp.PromptTokenCount,
+		CompletionTokens: llamaResp.GenerationTokenCount,
+		TotalTokens:      llamaResp.PromptTokenCount + llamaResp.GenerationTokenCount,
+	}, nil
+}
+
+// llama3StopReason maps Bedrock's Llama 3 stop_reason to an OpenAI finish
+// reason so downstream consumers don't need to know about Bedrock.
+func llama3StopReason(reason string) string {
+	switch reason {
+	case "stop":
+		return "stop"
+	case "length":
+		return "length"
+	default:
+		return "stop"
+	}
+}
@@ -0,0 +1,28 @@
+package aws
+
+// Request is the Bedrock wire format for anthropic.* models. Its fields
+// mirror anthropic.Request closely enough that copier.Copy can translate
+// straight across; AnthropicVersion replaces the "model" field Bedrock
+// doesn't want in the body (the model id is already part of the URL/ModelId).
+type Request struct {
+	AnthropicVersion string             `json:"anthropic_version"`
+	MaxTokens        int                `json:"max_tokens,omitempty"`
+	System           string             `json:"system,omitempty"`
+	Messages         []anthropicMessage `json:"messages"`
+	Temperature      float64            `json:"temperature,omitempty"`
+	TopP             float64            `json:"top_p,omitempty"`
+	TopK             int                `json:"top_k,omitempty"`
+	StopSequences    []string           `json:"stop_sequences,omitempty"`
+	// Tools and ToolChoice are passed through verbatim from anthropic.Request
+	// (left untyped so copier.Copy carries whatever shape that package
+	// already builds for OpenAI-style tool/function-calling requests).
+	Tools      any `json:"tools,omitempty"`
+	ToolChoice any `json:"tool_choice,omitempty"`
+}
+
+// anthropicMessage mirrors anthropic.Message; kept local so copier.Copy has a
+// concrete destination type to decode the role/content pairs into.
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content any    `json:"content"`
+}
@@ -0,0 +1,117 @@
+package aws
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// regionTarget is one entry in a channel's region/profile failover list.
+type regionTarget struct {
+	// Region is the AWS region the request is sent to (and signed for).
+	Region string
+	// ProfileID, when set, is a cross-region inference profile id (e.g.
+	// "us.anthropic.claude-3-5-sonnet-20240620-v1:0") used as the Bedrock
+	// ModelId instead of the plain per-region model id. Profiles route a
+	// single invocation across whichever regions AWS picks on its own, but
+	// we still need a region to sign the request for.
+	//
+	// https://docs.aws.amazon.com/bedrock/latest/userguide/cross-region-inference.html
+	ProfileID string
+}
+
+// parseRegionTargets splits a channel's BaseURL into the ordered list of
+// regions Handler/StreamHandler try in turn. A plain BaseURL with no comma
+// (the only form older channels use) yields a single target, so existing
+// channels keep working unchanged. Each comma-separated entry is either a
+// bare region ("us-west-2") or "<region>|<inference-profile-id>" to route
+// that region's attempt through a cross-region inference profile.
+func parseRegionTargets(baseURL string) []regionTarget {
+	parts := strings.Split(baseURL, ",")
+	targets := make([]regionTarget, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if region, profile, ok := strings.Cut(p, "|"); ok && profile != "" {
+			targets = append(targets, regionTarget{Region: region, ProfileID: profile})
+		} else {
+			targets = append(targets, regionTarget{Region: p})
+		}
+	}
+	return targets
+}
+
+// CtxKeyAwsRegion is the gin context key Handler/StreamHandler set to the
+// region that actually served the request, so request logging and the usage
+// record can show which one of a channel's regions was used.
+const CtxKeyAwsRegion = "aws_region"
+
+const regionCooldown = 30 * time.Second
+
+// regionCooldowns remembers, per channel+region, when a region that just
+// returned a throttling/unavailable error is safe to retry again. It's
+// process-local and intentionally simple — a throttle shouldn't need a
+// database round trip to be remembered for the next request.
+var regionCooldowns = struct {
+	sync.Mutex
+	until map[string]time.Time
+}{until: make(map[string]time.Time)}
+
+func regionCoolingDown(channelId int, region string) bool {
+	key := cooldownKey(channelId, region)
+	regionCooldowns.Lock()
+	defer regionCooldowns.Unlock()
+	until, ok := regionCooldowns.until[key]
+	return ok && time.Now().Before(until)
+}
+
+func coolDownRegion(channelId int, region string) {
+	key := cooldownKey(channelId, region)
+	regionCooldowns.Lock()
+	defer regionCooldowns.Unlock()
+	regionCooldowns.until[key] = time.Now().Add(regionCooldown)
+}
+
+func cooldownKey(channelId int, region string) string {
+	return fmt.Sprintf("%d:%s", channelId, region)
+}
+
+// orderedRegionTargets returns targets with any region currently cooling
+// down moved to the back of the line, rather than dropped — if every region
+// is throttled we'd still rather try them than fail outright. Both groups
+// keep their configured order.
+func orderedRegionTargets(channelId int, targets []regionTarget) []regionTarget {
+	fresh := make([]regionTarget, 0, len(targets))
+	cooling := make([]regionTarget, 0)
+	for _, t := range targets {
+		if regionCoolingDown(channelId, t.Region) {
+			cooling = append(cooling, t)
+		} else {
+			fresh = append(fresh, t)
+		}
+	}
+	return append(fresh, cooling...)
+}
+
+// isRetryableBedrockError reports whether err is one of the Bedrock errors
+// worth failing over to another region for, rather than surfacing straight
+// to the caller. It matches on the Bedrock exception name embedded in the
+// error message by both the SDK (types package error strings) and the raw
+// sigv4 HTTP path (bedrockHTTPError/bedrockEventStreamExceptionError), plus
+// the HTTP status codes those same conditions surface as when a Bedrock
+// error body doesn't carry a recognizable __type.
+func isRetryableBedrockError(err error) bool {
+	msg := err.Error()
+	for _, s := range []string{
+		"ThrottlingException", "ServiceUnavailableException", "ModelNotReadyException",
+		"status 429", "status 500", "status 503", "status 529",
+	} {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
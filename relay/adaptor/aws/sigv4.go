@@ -0,0 +1,116 @@
+package aws
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4signer "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+	"github.com/songquanpeng/one-api/model"
+	"github.com/songquanpeng/one-api/relay/channel"
+)
+
+const bedrockServiceName = "bedrock"
+
+// useSigV4HTTP reports whether a channel has opted out of the
+// bedrockruntime.Client SDK path in favor of a raw, SigV4-signed HTTP
+// request. Set "sigv4_http" in the channel's Other field to enable it; this
+// skips constructing a bedrockruntime.Client and its event-stream decoder
+// (only the SDK's v4 signer is used) and lets Bedrock traffic flow through
+// the same util.HTTPClient (proxy, timeouts, ...) as every other channel.
+func useSigV4HTTP(ch *model.Channel) bool {
+	return strings.Contains(ch.Other, "sigv4_http")
+}
+
+// awsCredentials is the minimal static credential pair parsed out of a
+// channel's Key, shared by both the SDK client path and the raw HTTP path.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func parseAwsCredentials(ch *model.Channel) (*awsCredentials, error) {
+	ks := strings.Split(ch.Key, "\n")
+	if len(ks) != 2 {
+		return nil, errors.New("invalid key")
+	}
+	return &awsCredentials{AccessKeyID: ks[0], SecretAccessKey: ks[1]}, nil
+}
+
+// invokeModelURL builds the Bedrock runtime endpoint for a region/model,
+// optionally targeting the response-streaming variant of InvokeModel.
+//
+// https://docs.aws.amazon.com/bedrock/latest/APIReference/API_runtime_InvokeModel.html
+func invokeModelURL(region, awsModelId string, stream bool) string {
+	action := "invoke"
+	if stream {
+		action = "invoke-with-response-stream"
+	}
+	return fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com/model/%s/%s", region, url.PathEscape(awsModelId), action)
+}
+
+// signedInvokeModelRequest builds and SigV4-signs an InvokeModel HTTP
+// request for the given region/model and sends it through channel.DoRequest
+// so it shares util.HTTPClient with every other adaptor. A non-2xx response
+// is closed and returned as a bedrockHTTPError.
+func signedInvokeModelRequest(c *gin.Context, creds *awsCredentials, region, awsModelId string, body []byte, stream bool) (*http.Response, error) {
+	reqURL := invokeModelURL(region, awsModelId, stream)
+	req, err := http.NewRequestWithContext(c.Request.Context(), http.MethodPost, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrap(err, "new request")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if stream {
+		req.Header.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	payloadHash := sha256.Sum256(body)
+	signer := v4signer.NewSigner()
+	cred := aws.Credentials{AccessKeyID: creds.AccessKeyID, SecretAccessKey: creds.SecretAccessKey}
+	if err := signer.SignHTTP(c.Request.Context(), cred, req, hex.EncodeToString(payloadHash[:]), bedrockServiceName, region, time.Now()); err != nil {
+		return nil, errors.Wrap(err, "sign request")
+	}
+
+	resp, err := channel.DoRequest(c, req)
+	if err != nil {
+		return nil, errors.Wrap(err, "do request")
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, bedrockHTTPError(resp)
+	}
+	return resp, nil
+}
+
+// bedrockErrorBody is the error shape Bedrock's InvokeModel HTTP endpoints
+// return on a non-2xx response, e.g.
+// {"message":"...","__type":"ThrottlingException"}.
+type bedrockErrorBody struct {
+	Message string `json:"message"`
+	Type    string `json:"__type"`
+}
+
+// bedrockHTTPError turns a non-2xx InvokeModel response into a real error,
+// embedding the Bedrock exception name (e.g. "ThrottlingException") in the
+// message so isRetryableBedrockError can still pattern-match it the same way
+// it does for SDK-path errors.
+func bedrockHTTPError(resp *http.Response) error {
+	body, _ := io.ReadAll(resp.Body)
+	var be bedrockErrorBody
+	if err := json.Unmarshal(body, &be); err != nil || be.Type == "" {
+		return errors.Errorf("bedrock invoke failed: status %d: %s", resp.StatusCode, string(body))
+	}
+	return errors.Errorf("bedrock invoke failed: status %d: %s: %s", resp.StatusCode, be.Type, be.Message)
+}
@@ -0,0 +1,142 @@
+// Package model holds the OpenAI-compatible request/response shapes shared
+// across every adaptor in relay/adaptor/*, so each adaptor only has to
+// translate to and from its own provider's wire format.
+package model
+
+// Message is one OpenAI chat message. Content is `any` because the wire
+// format allows either a plain string or a list of multi-modal content
+// parts; use StringContent to read it back as plain text regardless of
+// which shape came in.
+type Message struct {
+	Role       string  `json:"role"`
+	Content    any     `json:"content,omitempty"`
+	Name       *string `json:"name,omitempty"`
+	ToolCalls  []Tool  `json:"tool_calls,omitempty"`
+	ToolCallId string  `json:"tool_call_id,omitempty"`
+}
+
+// StringContent renders Content as plain text regardless of whether it came
+// in as a bare string or a list of content parts, for adaptors (e.g. Llama 3)
+// that only understand flat text prompts.
+func (m Message) StringContent() string {
+	switch v := m.Content.(type) {
+	case string:
+		return v
+	case []any:
+		var text string
+		for _, partAny := range v {
+			part, ok := partAny.(map[string]any)
+			if !ok {
+				continue
+			}
+			if part["type"] == "text" {
+				if s, ok := part["text"].(string); ok {
+					text += s
+				}
+			}
+		}
+		return text
+	default:
+		return ""
+	}
+}
+
+// Function is an OpenAI function/tool definition (when sent as part of a
+// request's tools/functions list) or a function call (when it appears on a
+// message's tool_calls/function_call).
+type Function struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+	Arguments   string `json:"arguments,omitempty"`
+}
+
+// Tool is an OpenAI tool_calls entry (or, inside a request's tools list, a
+// tool definition). Index is only set on streaming deltas, where it
+// identifies which in-progress tool call a fragment belongs to.
+type Tool struct {
+	Id       string   `json:"id,omitempty"`
+	Type     string   `json:"type,omitempty"`
+	Function Function `json:"function"`
+	Index    *int     `json:"index,omitempty"`
+}
+
+// GeneralOpenAIRequest is the superset of fields accepted across OpenAI's
+// chat completions and legacy completions endpoints; adaptors read whichever
+// subset their provider understands.
+type GeneralOpenAIRequest struct {
+	Model       string     `json:"model,omitempty"`
+	Messages    []Message  `json:"messages,omitempty"`
+	Prompt      any        `json:"prompt,omitempty"`
+	Stream      bool       `json:"stream,omitempty"`
+	MaxTokens   int        `json:"max_tokens,omitempty"`
+	Temperature float64    `json:"temperature,omitempty"`
+	TopP        float64    `json:"top_p,omitempty"`
+	N           int        `json:"n,omitempty"`
+	Stop        any        `json:"stop,omitempty"`
+	Tools       []Tool     `json:"tools,omitempty"`
+	ToolChoice  any        `json:"tool_choice,omitempty"`
+	Functions   []Function `json:"functions,omitempty"`
+}
+
+// Usage is the token-accounting block returned to clients as the response's
+// "usage" field, and reused internally as the value the billing step charges
+// quota against. CacheCreationInputTokens/CacheReadInputTokens are populated
+// only by providers with a prompt-caching feature (currently Anthropic on
+// Bedrock); they're informational on the client-facing response and are
+// folded into an effective prompt-token count only for billing purposes, not
+// written back into PromptTokens/TotalTokens here.
+type Usage struct {
+	PromptTokens             int `json:"prompt_tokens"`
+	CompletionTokens         int `json:"completion_tokens"`
+	TotalTokens              int `json:"total_tokens"`
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	CacheReadInputTokens     int `json:"cache_read_input_tokens,omitempty"`
+}
+
+// TextResponse is a non-streaming OpenAI chat-completion response.
+type TextResponse struct {
+	Id      string               `json:"id"`
+	Object  string               `json:"object"`
+	Created int64                `json:"created"`
+	Model   string               `json:"model"`
+	Choices []TextResponseChoice `json:"choices"`
+	Usage   Usage                `json:"usage"`
+}
+
+type TextResponseChoice struct {
+	Index        int     `json:"index"`
+	Message      Message `json:"message"`
+	FinishReason string  `json:"finish_reason,omitempty"`
+}
+
+// ChatCompletionsStreamResponse is one OpenAI chat-completion SSE chunk.
+type ChatCompletionsStreamResponse struct {
+	Id      string                                 `json:"id"`
+	Object  string                                 `json:"object"`
+	Created int64                                  `json:"created"`
+	Model   string                                 `json:"model"`
+	Choices []ChatCompletionsStreamResponseChoice `json:"choices"`
+}
+
+type ChatCompletionsStreamResponseChoice struct {
+	Index        int     `json:"index"`
+	Delta        Message `json:"delta"`
+	FinishReason *string `json:"finish_reason,omitempty"`
+}
+
+// Error is the body of an OpenAI-shaped error response.
+type Error struct {
+	Message string `json:"message"`
+	Type    string `json:"type,omitempty"`
+	Param   string `json:"param,omitempty"`
+	Code    any    `json:"code,omitempty"`
+}
+
+// ErrorWithStatusCode pairs an Error with the HTTP status it should be
+// returned with; StatusCode is never serialized, it just tells the relay
+// layer which response code to write alongside Error.
+type ErrorWithStatusCode struct {
+	Error      Error `json:"error"`
+	StatusCode int   `json:"-"`
+}